@@ -0,0 +1,257 @@
+package filemanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrJobLimit is returned by JobManager.Start when the user has reached
+// their maximum number of concurrently running jobs.
+var ErrJobLimit = errors.New("maximum concurrent jobs reached")
+
+// Job tracks a single websocket-launched command from start to finish,
+// replacing the ad-hoc 'done bool' shared between the command handler and
+// its waiter goroutine.
+type Job struct {
+	ID       string    `json:"id"`
+	User     string    `json:"user"`
+	Argv     []string  `json:"argv"`
+	Cwd      string    `json:"cwd"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end,omitempty"`
+	ExitCode int       `json:"exitCode"`
+
+	mu          sync.Mutex
+	cmd         *exec.Cmd
+	cancel      context.CancelFunc
+	output      bytes.Buffer
+	outputLimit int64
+	done        chan struct{}
+	waitErr     error
+}
+
+// Output returns a copy of everything captured so far, for both live
+// polling and the /log replay endpoint.
+func (j *Job) Output() []byte {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := make([]byte, j.output.Len())
+	copy(out, j.output.Bytes())
+	return out
+}
+
+// Write implements io.Writer, capturing combined stdout/stderr up to the
+// job's output byte cap. Once the cap is hit, further writes are dropped;
+// how many bytes were dropped is not recorded.
+func (j *Job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	n := len(p)
+
+	if j.outputLimit > 0 && int64(j.output.Len()) >= j.outputLimit {
+		return n, nil
+	}
+
+	if j.outputLimit > 0 && int64(j.output.Len()+len(p)) > j.outputLimit {
+		p = p[:j.outputLimit-int64(j.output.Len())]
+	}
+
+	if _, err := j.output.Write(p); err != nil {
+		return 0, err
+	}
+
+	// Always report the full length written, even though we may have
+	// buffered less: io.Copy (used internally by os/exec to pump
+	// stdout/stderr) treats a short return as io.ErrShortWrite and closes
+	// the pipe, killing the child with SIGPIPE on its next write.
+	return n, nil
+}
+
+// Done reports whether the job's process has exited.
+func (j *Job) Done() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Signal sends a signal to the job's process, used to honor an inbound
+// {"signal":"SIGINT"} control frame. DELETE /api/jobs/{id} goes through
+// JobManager.Cancel instead.
+func (j *Job) Signal(sig os.Signal) error {
+	j.mu.Lock()
+	proc := j.cmd.Process
+	j.mu.Unlock()
+
+	if proc == nil {
+		return nil
+	}
+
+	return proc.Signal(sig)
+}
+
+// JobManager owns every in-flight and completed Job, enforcing each user's
+// concurrency limits and making jobs addressable by ID for the REST API.
+type JobManager struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running map[string]int // username -> count of running jobs
+}
+
+// NewJobManager creates an empty manager.
+func NewJobManager() *JobManager {
+	return &JobManager{
+		jobs:    map[string]*Job{},
+		running: map[string]int{},
+	}
+}
+
+// Get looks up a job by ID.
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+// List returns every tracked job, most recently started first.
+func (m *JobManager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+
+	for i := 1; i < len(jobs); i++ {
+		for k := i; k > 0 && jobs[k].Start.After(jobs[k-1].Start); k-- {
+			jobs[k], jobs[k-1] = jobs[k-1], jobs[k]
+		}
+	}
+
+	return jobs
+}
+
+// Reserve claims one of the user's concurrent-job slots, returning
+// ErrJobLimit if they're already at c.User.MaxConcurrentJobs. The caller
+// must invoke the returned release func exactly once, however the command
+// it's running for ends up terminating. This is the same accounting Start
+// uses for tracked Jobs; callers that can't go through Start (e.g. the
+// pty-backed terminal, which owns cmd's stdio itself) call it directly so
+// those sessions are still subject to the user's concurrency limit.
+func (m *JobManager) Reserve(c *RequestContext) (func(), error) {
+	username := c.User.Username
+
+	m.mu.Lock()
+	if c.User.MaxConcurrentJobs > 0 && m.running[username] >= c.User.MaxConcurrentJobs {
+		m.mu.Unlock()
+		return nil, ErrJobLimit
+	}
+	m.running[username]++
+	m.mu.Unlock()
+
+	released := false
+	return func() {
+		if released {
+			return
+		}
+		released = true
+
+		m.mu.Lock()
+		m.running[username]--
+		m.mu.Unlock()
+	}, nil
+}
+
+// Start launches argv under cwd on behalf of the request's user, enforcing
+// that user's concurrency, timeout and output caps. The returned Job is
+// already running; poll Done to learn when it has exited.
+func (m *JobManager) Start(c *RequestContext, argv []string, cwd string) (*Job, error) {
+	username := c.User.Username
+
+	release, err := m.Reserve(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if c.User.JobTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.User.JobTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = cwd
+	start := applyResourceLimits(cmd, c.User)
+
+	job := &Job{
+		ID:          uuid.NewString(),
+		User:        username,
+		Argv:        argv,
+		Cwd:         cwd,
+		Start:       time.Now(),
+		cmd:         cmd,
+		cancel:      cancel,
+		outputLimit: c.User.MaxOutputBytes,
+		done:        make(chan struct{}),
+	}
+	cmd.Stdout = job
+	cmd.Stderr = job
+
+	if err := start(); err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	go func() {
+		job.waitErr = cmd.Wait()
+		job.mu.Lock()
+		job.End = time.Now()
+		if cmd.ProcessState != nil {
+			job.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		job.mu.Unlock()
+		cancel()
+		close(job.done)
+
+		release()
+	}()
+
+	return job, nil
+}
+
+// Cancel stops a running job, used by DELETE /api/jobs/{id} and by the
+// command handler when its websocket connection closes.
+func (m *JobManager) Cancel(id string) error {
+	job, ok := m.Get(id)
+	if !ok {
+		return errors.New("job not found")
+	}
+
+	job.cancel()
+	return nil
+}
+
+// jobManager is the process-wide job registry shared by the command
+// handler and the REST endpoints below.
+var jobManager = NewJobManager()
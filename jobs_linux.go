@@ -0,0 +1,28 @@
+//go:build linux
+
+package filemanager
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// applyResourceLimits groups the job's process into its own process group
+// so Cancel can signal the whole tree it spawns.
+//
+// CPU/memory caps are intentionally not enforced here: syscall.Setrlimit
+// only affects the calling process, and this process is the whole
+// filebrowser server, not the job's child. Lowering RLIMIT_CPU here would
+// apply to the server itself — since RLIMIT_CPU is cumulative CPU time,
+// a server that has already used more than the configured limit would be
+// killed by SIGXCPU the moment this runs. Enforcing a real per-job cap
+// requires a re-exec helper (set the limit in the child after fork, before
+// exec) or assigning the process group to a cgroup; neither is wired up
+// yet, so user.MaxCPUSeconds/MaxMemoryBytes are not applied.
+func applyResourceLimits(cmd *exec.Cmd, user User) func() error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd.Start
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package filemanager
+
+import "os/exec"
+
+// applyResourceLimits is a no-op outside Linux. As on Linux, no CPU/memory
+// caps are applied, so jobs only get the concurrency, wall-clock and
+// output limits enforced by JobManager itself.
+func applyResourceLimits(cmd *exec.Cmd, user User) func() error {
+	return cmd.Start
+}
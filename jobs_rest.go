@@ -0,0 +1,74 @@
+package filemanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// jobIDFromPath extracts the {id} segment from a /api/jobs/{id}[/log]
+// style path, mirroring how the rest of the package pulls path params out
+// of r.URL.Path rather than a router's named captures.
+func jobIDFromPath(r *http.Request, suffix string) string {
+	path := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	path = strings.TrimSuffix(path, suffix)
+	return strings.Trim(path, "/")
+}
+
+// listJobsHandler handles GET /api/jobs, listing every job the job
+// manager is tracking for this process that belongs to the caller.
+func listJobsHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	all := jobManager.List()
+	owned := make([]*Job, 0, len(all))
+	for _, j := range all {
+		if j.User == c.User.Username {
+			owned = append(owned, j)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return http.StatusOK, json.NewEncoder(w).Encode(owned)
+}
+
+// getJobHandler handles GET /api/jobs/{id}.
+func getJobHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	job, ok := jobManager.Get(jobIDFromPath(r, ""))
+	if !ok || job.User != c.User.Username {
+		return http.StatusNotFound, nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return http.StatusOK, json.NewEncoder(w).Encode(job)
+}
+
+// killJobHandler handles DELETE /api/jobs/{id}, cancelling the job's
+// context, which terminates its process.
+func killJobHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	job, ok := jobManager.Get(jobIDFromPath(r, ""))
+	if !ok || job.User != c.User.Username {
+		return http.StatusNotFound, nil
+	}
+
+	if err := jobManager.Cancel(job.ID); err != nil {
+		return http.StatusNotFound, nil
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// jobLogHandler handles GET /api/jobs/{id}/log, replaying everything the
+// job has written to stdout/stderr so far.
+func jobLogHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	job, ok := jobManager.Get(jobIDFromPath(r, "/log"))
+	if !ok || job.User != c.User.Username {
+		return http.StatusNotFound, nil
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := w.Write(job.Output())
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,77 @@
+package filemanager
+
+import (
+	"errors"
+	"testing"
+)
+
+func reserveCtx(username string, maxConcurrent int) *RequestContext {
+	return &RequestContext{User: &User{Username: username, MaxConcurrentJobs: maxConcurrent}}
+}
+
+func TestJobManagerReserveEnforcesLimit(t *testing.T) {
+	m := NewJobManager()
+	c := reserveCtx("alice", 2)
+
+	release1, err := m.Reserve(c)
+	if err != nil {
+		t.Fatalf("Reserve 1: %v", err)
+	}
+	release2, err := m.Reserve(c)
+	if err != nil {
+		t.Fatalf("Reserve 2: %v", err)
+	}
+
+	if _, err := m.Reserve(c); !errors.Is(err, ErrJobLimit) {
+		t.Fatalf("Reserve 3 err = %v, want ErrJobLimit", err)
+	}
+
+	release1()
+
+	if _, err := m.Reserve(c); err != nil {
+		t.Fatalf("Reserve after release: %v", err)
+	}
+
+	release2()
+}
+
+func TestJobManagerReserveReleaseIsIdempotent(t *testing.T) {
+	m := NewJobManager()
+	c := reserveCtx("bob", 1)
+
+	release, err := m.Reserve(c)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+
+	release()
+	release()
+
+	if _, err := m.Reserve(c); err != nil {
+		t.Fatalf("Reserve after double release: %v", err)
+	}
+}
+
+func TestJobManagerReserveUnlimited(t *testing.T) {
+	m := NewJobManager()
+	c := reserveCtx("carol", 0)
+
+	for i := 0; i < 5; i++ {
+		if _, err := m.Reserve(c); err != nil {
+			t.Fatalf("Reserve %d with no limit: %v", i, err)
+		}
+	}
+}
+
+func TestJobManagerReservePerUser(t *testing.T) {
+	m := NewJobManager()
+	alice := reserveCtx("alice", 1)
+	bob := reserveCtx("bob", 1)
+
+	if _, err := m.Reserve(alice); err != nil {
+		t.Fatalf("Reserve alice: %v", err)
+	}
+	if _, err := m.Reserve(bob); err != nil {
+		t.Fatalf("Reserve bob should be unaffected by alice's slot: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package filemanager
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is how long a single write pump message is allowed to
+	// take before the connection is considered dead.
+	writeWait = 10 * time.Second
+
+	// pongWait/pingPeriod implement the standard gorilla/websocket
+	// keepalive dance: the server pings more often than the client's
+	// read deadline, and resets that deadline whenever a pong arrives.
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
+
+	// maxMessageSize caps a single inbound frame, so a misbehaving or
+	// hostile client can't force unbounded buffering.
+	maxMessageSize = 1 << 20 // 1 MiB
+)
+
+// allowedOrigins, when non-empty, restricts which Origin header values the
+// upgrader will accept. It's populated from server config via
+// SetAllowedOrigins; an empty list falls back to gorilla's same-origin
+// default rather than allowing everything, so reverse-proxied deployments
+// have to opt in explicitly.
+var allowedOrigins []string
+
+// SetAllowedOrigins configures the CheckOrigin policy used by every
+// websocket endpoint in this package.
+func SetAllowedOrigins(origins []string) {
+	allowedOrigins = origins
+}
+
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(allowedOrigins) == 0 {
+		return origin == "https://"+r.Host || origin == "http://"+r.Host
+	}
+
+	for _, o := range allowedOrigins {
+		if o == origin || o == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SafeConn wraps a gorilla/websocket connection so that every handler in
+// this package gets ping/pong keepalives, read/write deadlines and a
+// single writer goroutine for free, instead of writing from multiple
+// goroutines directly on *websocket.Conn (which the search callback and
+// command's job streaming loop both used to do, unsynchronized).
+type SafeConn struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+	send    chan wsMessage
+	closed  chan struct{}
+	once    sync.Once
+}
+
+type wsMessage struct {
+	kind int
+	data []byte
+}
+
+// NewSafeConn upgrades r/w to a websocket and wraps it, starting the write
+// pump and ping loop immediately.
+func NewSafeConn(w http.ResponseWriter, r *http.Request) (*SafeConn, error) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	sc := &SafeConn{
+		conn:   conn,
+		send:   make(chan wsMessage, 16),
+		closed: make(chan struct{}),
+	}
+
+	go sc.writePump()
+	return sc, nil
+}
+
+// WriteMessage queues a message on the write pump, returning once it's
+// been handed off (not once it's actually hit the wire), so callers never
+// write directly on the underlying *websocket.Conn.
+func (sc *SafeConn) WriteMessage(kind int, data []byte) error {
+	select {
+	case sc.send <- wsMessage{kind: kind, data: data}:
+		return nil
+	case <-sc.closed:
+		return websocket.ErrCloseSent
+	}
+}
+
+// ReadMessage reads the next inbound frame. Reads are never concurrent
+// with each other in this package's handlers, so unlike writes they pass
+// straight through.
+func (sc *SafeConn) ReadMessage() (int, []byte, error) {
+	return sc.conn.ReadMessage()
+}
+
+// Close stops the write pump and closes the underlying connection. Safe to
+// call more than once.
+func (sc *SafeConn) Close() error {
+	sc.once.Do(func() { close(sc.closed) })
+	return sc.conn.Close()
+}
+
+func (sc *SafeConn) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	// On any write failure the connection is dead: close sc.closed so
+	// queued and future WriteMessage calls fail fast instead of blocking
+	// forever on a send channel nothing will ever drain again. Without
+	// this, a single wedged connection head-of-line-blocks anything that
+	// writes to it synchronously (e.g. watchHub.dispatch fanning out to
+	// every subscriber).
+	for {
+		select {
+		case msg := <-sc.send:
+			sc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := sc.conn.WriteMessage(msg.kind, msg.data); err != nil {
+				sc.once.Do(func() { close(sc.closed) })
+				return
+			}
+
+		case <-ticker.C:
+			sc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := sc.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				sc.once.Do(func() { close(sc.closed) })
+				return
+			}
+
+		case <-sc.closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package index implements a persistent, incrementally updated inverted
+// index over file contents, used to back full-text search in filemanager.
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Analyzer turns raw file content into a normalized stream of tokens that
+// get written into the index. The default analyzer lowercases input and
+// splits on unicode word boundaries; stemming can be layered on top by
+// setting Stem, which Tokenize applies to every token.
+type Analyzer struct {
+	Lowercase bool
+	Stem      func(string) string
+}
+
+// DefaultAnalyzer lowercases and splits words, without stemming.
+func DefaultAnalyzer() *Analyzer {
+	return &Analyzer{Lowercase: true}
+}
+
+// Tokenize splits text into analyzed tokens, in order, duplicates included
+// (callers that need positions/term-frequency can rely on the ordering).
+func (a *Analyzer) Tokenize(text string) []string {
+	if a.Lowercase {
+		text = strings.ToLower(text)
+	}
+
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	if a.Stem == nil {
+		return fields
+	}
+
+	tokens := make([]string, len(fields))
+	for i, f := range fields {
+		tokens[i] = a.Stem(f)
+	}
+
+	return tokens
+}
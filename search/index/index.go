@@ -0,0 +1,719 @@
+package index
+
+import (
+	"encoding/json"
+	"math"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketPostings = []byte("postings") // term -> json-encoded postings list
+	bucketDocs     = []byte("docs")     // path -> json-encoded docMeta
+	bucketMeta     = []byte("meta")     // misc index stats
+)
+
+// docMeta holds what's needed to score and display a hit without re-reading
+// the file from disk.
+type docMeta struct {
+	Path     string   `json:"path"`
+	Size     int64    `json:"size"`
+	Modified int64    `json:"modified"`
+	Mime     string   `json:"mime"`
+	Length   int      `json:"length"` // token count, for BM25 normalization
+	Terms    []string `json:"terms"`  // distinct terms this doc contributed, so re-indexing can find and drop its stale postings
+}
+
+// posting is a single document's contribution to a term's postings list.
+type posting struct {
+	Path      string `json:"path"`
+	Freq      int    `json:"freq"`
+	Line      int    `json:"line"`      // first line the term appears on, for snippets
+	Positions []int  `json:"positions"` // token offsets within the doc, for phrase adjacency checks
+}
+
+// Hit is a single ranked search result.
+type Hit struct {
+	Path    string  `json:"path"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+	Line    int     `json:"line"`
+}
+
+// Stats summarizes the current state of the index, returned by the reindex
+// stats REST endpoint.
+type Stats struct {
+	Documents  int `json:"documents"`
+	Terms      int `json:"terms"`
+	IndexBytes int `json:"indexBytes"`
+}
+
+// BM25 free parameters. These match the defaults used by most full-text
+// search engines and don't need to be user tunable.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Index is a persistent inverted index over a single FileSystem root,
+// backed by a bbolt database so it survives restarts and can be queried
+// concurrently with incremental updates.
+type Index struct {
+	db       *bolt.DB
+	root     string
+	analyzer *Analyzer
+
+	mu       sync.RWMutex
+	docCount int
+	totalLen float64
+
+	watcher *fsnotify.Watcher
+	closed  chan struct{}
+}
+
+// Open opens (creating if necessary) the index database at dbPath, scoped
+// to the given filesystem root.
+func Open(dbPath, root string, analyzer *Analyzer) (*Index, error) {
+	db, err := bolt.Open(dbPath, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketPostings, bucketDocs, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if analyzer == nil {
+		analyzer = DefaultAnalyzer()
+	}
+
+	idx := &Index{db: db, root: root, analyzer: analyzer, closed: make(chan struct{})}
+	if err := idx.loadStats(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// Close stops the watcher, if running, and closes the underlying database.
+func (idx *Index) Close() error {
+	if idx.watcher != nil {
+		close(idx.closed)
+		idx.watcher.Close()
+	}
+	return idx.db.Close()
+}
+
+// Watch starts an fsnotify watcher over idx.root and keeps the index up to
+// date as files are created, written, renamed or removed. It runs until
+// Close is called.
+func (idx *Index) Watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	idx.watcher = w
+
+	err = filepath.Walk(idx.root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			return w.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	go idx.watchLoop()
+	return nil
+}
+
+func (idx *Index) watchLoop() {
+	for {
+		select {
+		case <-idx.closed:
+			return
+
+		case ev, ok := <-idx.watcher.Events:
+			if !ok {
+				return
+			}
+			idx.handleEvent(ev)
+
+		case <-idx.watcher.Errors:
+			// Nothing we can usefully do with a watcher error beyond
+			// dropping it; the next successful event keeps the index
+			// converging towards the on-disk state.
+		}
+	}
+}
+
+func (idx *Index) handleEvent(ev fsnotify.Event) {
+	rel, err := filepath.Rel(idx.root, ev.Name)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+
+	switch {
+	case ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		idx.Remove(rel)
+
+	case ev.Op&(fsnotify.Create|fsnotify.Write) != 0:
+		info, err := os.Stat(ev.Name)
+		if err != nil {
+			return
+		}
+
+		if info.IsDir() {
+			idx.watcher.Add(ev.Name)
+			return
+		}
+
+		idx.indexFile(ev.Name, info)
+	}
+}
+
+func (idx *Index) loadStats() error {
+	return idx.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketDocs).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var meta docMeta
+			if err := json.Unmarshal(v, &meta); err != nil {
+				continue
+			}
+			idx.docCount++
+			idx.totalLen += float64(meta.Length)
+		}
+		return nil
+	})
+}
+
+// Reindex walks root from scratch, skipping anything that doesn't look like
+// text, and rebuilds the postings lists.
+func (idx *Index) Reindex() error {
+	idx.mu.Lock()
+	idx.docCount = 0
+	idx.totalLen = 0
+	idx.mu.Unlock()
+
+	err := idx.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketPostings, bucketDocs} {
+			if err := tx.DeleteBucket(b); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(idx.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+
+		return idx.indexFile(path, info)
+	})
+}
+
+// isBinary sniffs the first chunk of a file to decide whether it's worth
+// tokenizing, mirroring the common "contains a NUL byte" heuristic.
+func isBinary(data []byte) bool {
+	for _, b := range data {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *Index) indexFile(path string, info os.FileInfo) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil // skip unreadable files rather than aborting the walk
+	}
+
+	sniff := data
+	if len(sniff) > 512 {
+		sniff = sniff[:512]
+	}
+	if isBinary(sniff) {
+		return nil
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	rel, err := filepath.Rel(idx.root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+
+	return idx.upsert(rel, data, info, mimeType)
+}
+
+func (idx *Index) upsert(rel string, data []byte, info os.FileInfo, mimeType string) error {
+	lines := strings.Split(string(data), "\n")
+
+	// term -> (lineNo, freq, positions)
+	freq := make(map[string]*posting)
+	tokenCount := 0
+	for lineNo, line := range lines {
+		for _, tok := range idx.analyzer.Tokenize(line) {
+			p, ok := freq[tok]
+			if !ok {
+				p = &posting{Path: rel, Line: lineNo + 1}
+				freq[tok] = p
+			}
+			p.Freq++
+			p.Positions = append(p.Positions, tokenCount)
+
+			tokenCount++
+		}
+	}
+
+	terms := make([]string, 0, len(freq))
+	for term := range freq {
+		terms = append(terms, term)
+	}
+
+	meta := docMeta{
+		Path:     rel,
+		Size:     info.Size(),
+		Modified: info.ModTime().Unix(),
+		Mime:     mimeType,
+		Length:   tokenCount,
+		Terms:    terms,
+	}
+
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		docs := tx.Bucket(bucketDocs)
+		postings := tx.Bucket(bucketPostings)
+
+		isNew := true
+		if old := docs.Get([]byte(rel)); old != nil {
+			isNew = false
+
+			var oldMeta docMeta
+			if json.Unmarshal(old, &oldMeta) == nil {
+				if err := idx.removePostings(postings, rel, oldMeta); err != nil {
+					return err
+				}
+			}
+		}
+
+		encoded, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+		if err := docs.Put([]byte(rel), encoded); err != nil {
+			return err
+		}
+
+		idx.mu.Lock()
+		if isNew {
+			idx.docCount++
+		}
+		idx.totalLen += float64(meta.Length)
+		idx.mu.Unlock()
+
+		for term, p := range freq {
+			if err := idx.addPosting(postings, term, p); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (idx *Index) addPosting(b *bolt.Bucket, term string, p *posting) error {
+	var list []posting
+	if raw := b.Get([]byte(term)); raw != nil {
+		json.Unmarshal(raw, &list)
+	}
+
+	list = append(list, *p)
+	encoded, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(term), encoded)
+}
+
+// removePostings deletes path's entries from every term in meta.Terms,
+// dropping the term key entirely once its postings list is empty. It
+// adjusts totalLen for the doc's old contribution but leaves docCount
+// alone: upsert keeps the count when re-indexing an existing doc, and
+// Remove decrements it itself.
+func (idx *Index) removePostings(b *bolt.Bucket, path string, meta docMeta) error {
+	for _, term := range meta.Terms {
+		raw := b.Get([]byte(term))
+		if raw == nil {
+			continue
+		}
+
+		var list []posting
+		if err := json.Unmarshal(raw, &list); err != nil {
+			continue
+		}
+
+		kept := list[:0]
+		for _, p := range list {
+			if p.Path != path {
+				kept = append(kept, p)
+			}
+		}
+
+		if len(kept) == 0 {
+			if err := b.Delete([]byte(term)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		encoded, err := json.Marshal(kept)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(term), encoded); err != nil {
+			return err
+		}
+	}
+
+	idx.mu.Lock()
+	idx.totalLen -= float64(meta.Length)
+	idx.mu.Unlock()
+
+	return nil
+}
+
+// Remove deletes a document from the index, used when fsnotify reports a
+// delete or rename-away event.
+func (idx *Index) Remove(rel string) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		docs := tx.Bucket(bucketDocs)
+		old := docs.Get([]byte(rel))
+		if old == nil {
+			return nil
+		}
+
+		var meta docMeta
+		if json.Unmarshal(old, &meta) == nil {
+			if err := idx.removePostings(tx.Bucket(bucketPostings), rel, meta); err != nil {
+				return err
+			}
+
+			idx.mu.Lock()
+			idx.docCount--
+			idx.mu.Unlock()
+		}
+
+		return docs.Delete([]byte(rel))
+	})
+}
+
+// Stats reports the current size of the index.
+func (idx *Index) Stats() (Stats, error) {
+	var s Stats
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		s.Documents = tx.Bucket(bucketDocs).Stats().KeyN
+		s.Terms = tx.Bucket(bucketPostings).Stats().KeyN
+		s.IndexBytes = int(tx.Size())
+		return nil
+	})
+	return s, err
+}
+
+// Query runs a parsed Query against the index and returns ranked hits,
+// filtered by the allowed predicate (typically c.User.Allowed). OR branches
+// are evaluated independently and unioned, keeping each path's best score
+// across branches.
+func (idx *Index) Query(q *Query, allowed func(string) bool, limit int) ([]Hit, error) {
+	if len(q.Or) > 0 {
+		scores := make(map[string]float64)
+		lines := make(map[string]int)
+
+		for _, branch := range q.Or {
+			branchHits, err := idx.Query(branch, allowed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, h := range branchHits {
+				if h.Score > scores[h.Path] {
+					scores[h.Path] = h.Score
+				}
+				if _, ok := lines[h.Path]; !ok {
+					lines[h.Path] = h.Line
+				}
+			}
+		}
+
+		return rankHits(scores, lines, limit), nil
+	}
+
+	idx.mu.RLock()
+	avgLen := 1.0
+	if idx.docCount > 0 {
+		avgLen = idx.totalLen / float64(idx.docCount)
+	}
+	n := idx.docCount
+	idx.mu.RUnlock()
+
+	scores := make(map[string]float64)
+	lines := make(map[string]int)
+	matched := make(map[string]int) // path -> number of Must terms it contains
+
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		postings := tx.Bucket(bucketPostings)
+		docs := tx.Bucket(bucketDocs)
+
+		if len(q.Must) > 0 {
+			for _, term := range q.Must {
+				raw := postings.Get([]byte(term))
+				if raw == nil {
+					continue
+				}
+
+				var list []posting
+				if err := json.Unmarshal(raw, &list); err != nil {
+					continue
+				}
+
+				idf := math.Log(1 + (float64(n)-float64(len(list))+0.5)/(float64(len(list))+0.5))
+
+				for _, p := range list {
+					if allowed != nil && !allowed(p.Path) {
+						continue
+					}
+
+					if !matchesFilters(docs, p.Path, q) {
+						continue
+					}
+
+					docLen := avgLen
+					if rawDoc := docs.Get([]byte(p.Path)); rawDoc != nil {
+						var meta docMeta
+						if json.Unmarshal(rawDoc, &meta) == nil {
+							docLen = float64(meta.Length)
+						}
+					}
+
+					tf := float64(p.Freq)
+					score := idf * (tf * (bm25K1 + 1)) / (tf + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+					scores[p.Path] += score
+					matched[p.Path]++
+
+					if _, ok := lines[p.Path]; !ok {
+						lines[p.Path] = p.Line
+					}
+				}
+			}
+
+			// Terms are ANDed: drop docs missing any of them.
+			for path := range scores {
+				if matched[path] < len(q.Must) {
+					delete(scores, path)
+				}
+			}
+		} else if q.hasFieldFilters() {
+			// No bare terms to drive postings lookups, so the only way to
+			// honor a pure field filter (e.g. "name:apple") is to walk every
+			// indexed doc and test it directly.
+			c := docs.Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				path := string(k)
+				if allowed != nil && !allowed(path) {
+					continue
+				}
+				if !matchesFilters(docs, path, q) {
+					continue
+				}
+				scores[path] = 1
+				lines[path] = 0
+			}
+		}
+
+		for _, phrase := range q.Phrases {
+			matches, err := phraseMatches(postings, phrase)
+			if err != nil {
+				return err
+			}
+
+			for path := range scores {
+				if !matches[path] {
+					delete(scores, path)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, term := range q.MustNot {
+		raw, _ := idx.rawPostings(term)
+		for _, p := range raw {
+			delete(scores, p.Path)
+		}
+	}
+
+	return rankHits(scores, lines, limit), nil
+}
+
+func rankHits(scores map[string]float64, lines map[string]int, limit int) []Hit {
+	hits := make([]Hit, 0, len(scores))
+	for path, score := range scores {
+		hits = append(hits, Hit{Path: path, Score: score, Line: lines[path]})
+	}
+
+	sortHitsByScore(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits
+}
+
+// phraseMatches reports which paths contain terms in consecutive token
+// positions, i.e. an actual adjacent phrase rather than just all terms
+// present somewhere in the doc.
+func phraseMatches(postings *bolt.Bucket, terms []string) (map[string]bool, error) {
+	positionsByTerm := make([]map[string][]int, len(terms))
+	for i, term := range terms {
+		raw := postings.Get([]byte(term))
+		if raw == nil {
+			return nil, nil
+		}
+
+		var list []posting
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+
+		byPath := make(map[string][]int, len(list))
+		for _, p := range list {
+			byPath[p.Path] = p.Positions
+		}
+		positionsByTerm[i] = byPath
+	}
+
+	matches := make(map[string]bool)
+	for path, starts := range positionsByTerm[0] {
+		for _, start := range starts {
+			if phraseStartsAt(positionsByTerm, path, start) {
+				matches[path] = true
+				break
+			}
+		}
+	}
+
+	return matches, nil
+}
+
+// phraseStartsAt reports whether every term after the first occurs at the
+// expected consecutive offset from start, within path.
+func phraseStartsAt(positionsByTerm []map[string][]int, path string, start int) bool {
+	for i := 1; i < len(positionsByTerm); i++ {
+		want := start + i
+
+		found := false
+		for _, pos := range positionsByTerm[i][path] {
+			if pos == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (idx *Index) rawPostings(term string) ([]posting, error) {
+	var list []posting
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bucketPostings).Get([]byte(term))
+		if raw == nil {
+			return nil
+		}
+		return json.Unmarshal(raw, &list)
+	})
+	return list, err
+}
+
+func matchesFilters(docs *bolt.Bucket, path string, q *Query) bool {
+	if q.Name != "" && !strings.Contains(strings.ToLower(filepath.Base(path)), strings.ToLower(q.Name)) {
+		return false
+	}
+
+	if q.Ext == "" && q.MimeGlob == "" && q.MinSize == 0 && q.ModifiedAfter.IsZero() {
+		return true
+	}
+
+	raw := docs.Get([]byte(path))
+	if raw == nil {
+		return true
+	}
+
+	var meta docMeta
+	if json.Unmarshal(raw, &meta) != nil {
+		return true
+	}
+
+	if q.Ext != "" && filepath.Ext(path) != "."+q.Ext {
+		return false
+	}
+
+	if q.MimeGlob != "" {
+		ok, _ := filepath.Match(q.MimeGlob, meta.Mime)
+		if !ok {
+			return false
+		}
+	}
+
+	if q.MinSize > 0 && meta.Size < q.MinSize {
+		return false
+	}
+
+	if !q.ModifiedAfter.IsZero() && meta.Modified < q.ModifiedAfter.Unix() {
+		return false
+	}
+
+	return true
+}
+
+func sortHitsByScore(hits []Hit) {
+	for i := 1; i < len(hits); i++ {
+		for j := i; j > 0 && hits[j].Score > hits[j-1].Score; j-- {
+			hits[j], hits[j-1] = hits[j-1], hits[j]
+		}
+	}
+}
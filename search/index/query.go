@@ -0,0 +1,242 @@
+package index
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a parsed search expression. The grammar only supports
+// conjunctive matching plus negation for now; Or holds alternative Query
+// branches for top-level "OR" groups. Every phrase's terms are also added
+// to Must, so Phrases only needs to narrow the Must matches down to ones
+// where the terms appear adjacent, in order.
+type Query struct {
+	Must    []string
+	MustNot []string
+	Phrases [][]string
+
+	Name          string
+	Ext           string
+	MimeGlob      string
+	MinSize       int64
+	ModifiedAfter time.Time
+
+	Or []*Query
+}
+
+// ParseQuery parses a search expression into a Query, supporting bare
+// terms, "quoted phrases", -negated terms, and field:value filters (name:,
+// content:, ext:, size:>N, modified:>date, mime:glob), combined with AND
+// (the default) and OR.
+func ParseQuery(expr string, analyzer *Analyzer) *Query {
+	groups := splitOr(expr)
+
+	if len(groups) == 1 {
+		return parseAnd(groups[0], analyzer)
+	}
+
+	q := &Query{}
+	for _, g := range groups {
+		q.Or = append(q.Or, parseAnd(g, analyzer))
+	}
+	return q
+}
+
+// hasFieldFilters reports whether q carries any field filter (name:, ext:,
+// mime:, size:, modified:) that could match documents on its own, without a
+// bare search term to drive a postings lookup.
+func (q *Query) hasFieldFilters() bool {
+	return q.Name != "" || q.Ext != "" || q.MimeGlob != "" || q.MinSize > 0 || !q.ModifiedAfter.IsZero()
+}
+
+// Match reports whether a query (including its OR branches) is satisfied by
+// having every must-term present and no must-not term; full evaluation happens in
+// Index.Query, this is only used by callers that want a quick yes/no.
+func (q *Query) Match(terms map[string]bool) bool {
+	if len(q.Or) > 0 {
+		for _, branch := range q.Or {
+			if branch.Match(terms) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, m := range q.Must {
+		if !terms[m] {
+			return false
+		}
+	}
+
+	for _, m := range q.MustNot {
+		if terms[m] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splitOr(expr string) []string {
+	var groups []string
+	var cur strings.Builder
+	inQuotes := false
+
+	fields := tokenizeRaw(expr)
+	for _, f := range fields {
+		if !inQuotes && strings.EqualFold(f, "AND") {
+			continue
+		}
+		if !inQuotes && strings.EqualFold(f, "OR") {
+			groups = append(groups, cur.String())
+			cur.Reset()
+			continue
+		}
+
+		if strings.HasPrefix(f, `"`) {
+			inQuotes = !strings.HasSuffix(f, `"`) || len(f) == 1
+		}
+
+		if cur.Len() > 0 {
+			cur.WriteByte(' ')
+		}
+		cur.WriteString(f)
+	}
+
+	groups = append(groups, cur.String())
+	return groups
+}
+
+// tokenizeRaw splits the raw query string on spaces while keeping quoted
+// phrases intact, unlike Analyzer.Tokenize which is for indexed content.
+func tokenizeRaw(expr string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			cur.WriteRune(r)
+		case r == ' ' && !inQuotes:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+
+	return tokens
+}
+
+func parseAnd(expr string, analyzer *Analyzer) *Query {
+	q := &Query{}
+
+	for _, tok := range tokenizeRaw(expr) {
+		negate := false
+		if strings.HasPrefix(tok, "-") && len(tok) > 1 {
+			negate = true
+			tok = tok[1:]
+		}
+
+		if field, value, ok := strings.Cut(tok, ":"); ok {
+			if applyField(q, field, value, analyzer) {
+				continue
+			}
+		}
+
+		quoted := strings.HasPrefix(tok, `"`) && strings.HasSuffix(tok, `"`) && len(tok) > 1
+		tok = strings.Trim(tok, `"`)
+		if tok == "" {
+			continue
+		}
+
+		terms := analyzer.Tokenize(tok)
+		if negate {
+			q.MustNot = append(q.MustNot, terms...)
+			continue
+		}
+
+		q.Must = append(q.Must, terms...)
+		if quoted && len(terms) > 1 {
+			q.Phrases = append(q.Phrases, terms)
+		}
+	}
+
+	return q
+}
+
+// applyField handles a single "field:value" filter, returning false if
+// field isn't recognized (in which case the caller treats the whole token
+// as a plain search term instead).
+func applyField(q *Query, field, value string, analyzer *Analyzer) bool {
+	switch field {
+	case "name":
+		q.Name = value
+	case "content":
+		q.Must = append(q.Must, analyzer.Tokenize(value)...)
+	case "ext":
+		q.Ext = value
+	case "mime":
+		q.MimeGlob = value
+	case "size":
+		q.MinSize = parseSizeFilter(value)
+	case "modified":
+		if t, ok := parseModifiedFilter(value); ok {
+			q.ModifiedAfter = t
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// parseModifiedFilter parses values like ">2024-01-01" into the date they
+// name; only "after" comparisons are supported, matching the grammar the
+// request asked for.
+func parseModifiedFilter(value string) (time.Time, bool) {
+	value = strings.TrimPrefix(value, ">=")
+	value = strings.TrimPrefix(value, ">")
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// parseSizeFilter parses values like ">1M", ">=2K", "500" into bytes.
+func parseSizeFilter(value string) int64 {
+	value = strings.TrimPrefix(value, ">=")
+	value = strings.TrimPrefix(value, ">")
+
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		mult = 1 << 10
+		value = strings.TrimSuffix(value, "K")
+	case strings.HasSuffix(value, "M"):
+		mult = 1 << 20
+		value = strings.TrimSuffix(value, "M")
+	case strings.HasSuffix(value, "G"):
+		mult = 1 << 30
+		value = strings.TrimSuffix(value, "G")
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n * mult
+}
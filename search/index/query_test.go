@@ -0,0 +1,120 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQueryPlainTerms(t *testing.T) {
+	q := ParseQuery("foo bar", DefaultAnalyzer())
+
+	if !reflect.DeepEqual(q.Must, []string{"foo", "bar"}) {
+		t.Fatalf("Must = %v, want [foo bar]", q.Must)
+	}
+	if len(q.MustNot) != 0 || len(q.Phrases) != 0 || len(q.Or) != 0 {
+		t.Fatalf("unexpected extra state on %+v", q)
+	}
+}
+
+func TestParseQueryNegatedTerm(t *testing.T) {
+	q := ParseQuery("foo -bar", DefaultAnalyzer())
+
+	if !reflect.DeepEqual(q.Must, []string{"foo"}) {
+		t.Fatalf("Must = %v, want [foo]", q.Must)
+	}
+	if !reflect.DeepEqual(q.MustNot, []string{"bar"}) {
+		t.Fatalf("MustNot = %v, want [bar]", q.MustNot)
+	}
+}
+
+func TestParseQueryQuotedPhraseAddsPhrase(t *testing.T) {
+	q := ParseQuery(`"hello world"`, DefaultAnalyzer())
+
+	if !reflect.DeepEqual(q.Must, []string{"hello", "world"}) {
+		t.Fatalf("Must = %v, want [hello world]", q.Must)
+	}
+	if len(q.Phrases) != 1 || !reflect.DeepEqual(q.Phrases[0], []string{"hello", "world"}) {
+		t.Fatalf("Phrases = %v, want [[hello world]]", q.Phrases)
+	}
+}
+
+func TestParseQuerySingleWordQuotedPhraseIsNotAPhrase(t *testing.T) {
+	// A quoted single word has nothing to check adjacency against, so it
+	// should behave like a bare term.
+	q := ParseQuery(`"hello"`, DefaultAnalyzer())
+
+	if !reflect.DeepEqual(q.Must, []string{"hello"}) {
+		t.Fatalf("Must = %v, want [hello]", q.Must)
+	}
+	if len(q.Phrases) != 0 {
+		t.Fatalf("Phrases = %v, want none", q.Phrases)
+	}
+}
+
+func TestParseQueryOrSplitsIntoBranches(t *testing.T) {
+	q := ParseQuery("foo OR bar", DefaultAnalyzer())
+
+	if len(q.Or) != 2 {
+		t.Fatalf("Or = %v, want 2 branches", q.Or)
+	}
+	if !reflect.DeepEqual(q.Or[0].Must, []string{"foo"}) || !reflect.DeepEqual(q.Or[1].Must, []string{"bar"}) {
+		t.Fatalf("Or branches = %+v, want [foo] and [bar]", q.Or)
+	}
+}
+
+func TestParseQueryFieldFilters(t *testing.T) {
+	q := ParseQuery("name:report.pdf ext:pdf mime:application/* size:>1M modified:>2024-01-01", DefaultAnalyzer())
+
+	if q.Name != "report.pdf" {
+		t.Fatalf("Name = %q, want report.pdf", q.Name)
+	}
+	if q.Ext != "pdf" {
+		t.Fatalf("Ext = %q, want pdf", q.Ext)
+	}
+	if q.MimeGlob != "application/*" {
+		t.Fatalf("MimeGlob = %q, want application/*", q.MimeGlob)
+	}
+	if q.MinSize != 1<<20 {
+		t.Fatalf("MinSize = %d, want %d", q.MinSize, 1<<20)
+	}
+	if !q.ModifiedAfter.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ModifiedAfter = %v, want 2024-01-01", q.ModifiedAfter)
+	}
+	if len(q.Must) != 0 {
+		t.Fatalf("Must = %v, want none (no bare or content: terms)", q.Must)
+	}
+}
+
+func TestParseQueryContentFieldAddsMustTerms(t *testing.T) {
+	q := ParseQuery("content:hello", DefaultAnalyzer())
+
+	if !reflect.DeepEqual(q.Must, []string{"hello"}) {
+		t.Fatalf("Must = %v, want [hello]", q.Must)
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	q := ParseQuery("foo -bar", DefaultAnalyzer())
+
+	if !q.Match(map[string]bool{"foo": true}) {
+		t.Fatal("expected match when foo present and bar absent")
+	}
+	if q.Match(map[string]bool{"foo": true, "bar": true}) {
+		t.Fatal("expected no match when bar present")
+	}
+	if q.Match(map[string]bool{}) {
+		t.Fatal("expected no match when foo absent")
+	}
+}
+
+func TestQueryMatchOr(t *testing.T) {
+	q := ParseQuery("foo OR bar", DefaultAnalyzer())
+
+	if !q.Match(map[string]bool{"bar": true}) {
+		t.Fatal("expected OR branch match on bar alone")
+	}
+	if q.Match(map[string]bool{"baz": true}) {
+		t.Fatal("expected no match when neither branch satisfied")
+	}
+}
@@ -0,0 +1,113 @@
+package filemanager
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"vungoccuong/filebrowser/search/index"
+)
+
+// indexes caches one content index per filesystem root, since building it
+// is expensive and several users can share the same FileSystem.
+var (
+	indexes   = map[string]*index.Index{}
+	indexesMu sync.Mutex
+)
+
+// indexFor returns the (lazily opened) content index for a user's
+// filesystem root, starting its fsnotify watch loop the first time it's
+// opened.
+func indexFor(c *RequestContext) (*index.Index, error) {
+	root := string(c.User.FileSystem)
+
+	indexesMu.Lock()
+	defer indexesMu.Unlock()
+
+	if idx, ok := indexes[root]; ok {
+		return idx, nil
+	}
+
+	dbPath := filepath.Join(os.TempDir(), "filebrowser-index-"+sanitizeRoot(root)+".db")
+	idx, err := index.Open(dbPath, root, index.DefaultAnalyzer())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := idx.Reindex(); err != nil {
+		idx.Close()
+		return nil, err
+	}
+
+	if err := idx.Watch(); err != nil {
+		idx.Close()
+		return nil, err
+	}
+
+	indexes[root] = idx
+	return idx, nil
+}
+
+func sanitizeRoot(root string) string {
+	out := []byte(root)
+	for i, b := range out {
+		if b == '/' || b == '\\' || b == ':' {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+// reindexHandler triggers a full rebuild of the content index for the
+// current user's filesystem. Mounted at POST /api/search/reindex.
+func reindexHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	idx, err := indexFor(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if err := idx.Reindex(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// indexStatsHandler reports the size of the content index. Mounted at
+// GET /api/search/stats.
+func indexStatsHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	idx, err := indexFor(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	stats, err := idx.Stats()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return http.StatusOK, json.NewEncoder(w).Encode(stats)
+}
+
+// snippetFor reads the line the term was found on, for display alongside a
+// ranked hit.
+func snippetFor(root, relPath string, line int) string {
+	f, err := os.Open(filepath.Join(root, relPath))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if n == line {
+			return scanner.Text()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,300 @@
+package filemanager
+
+import (
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/creack/pty"
+	"github.com/gorilla/websocket"
+)
+
+// terminalSubprotocol is the websocket subprotocol clients opt into to get an
+// interactive, pty-backed shell instead of the fire-and-forget buffered mode.
+const terminalSubprotocol = "terminal.v1"
+
+// controlFrame is the small JSON protocol used by the client to send
+// out-of-band instructions, as opposed to raw keystrokes, over the terminal
+// websocket. Only one of the fields is expected to be set per message.
+type controlFrame struct {
+	Resize *struct {
+		Rows uint16 `json:"rows"`
+		Cols uint16 `json:"cols"`
+	} `json:"resize"`
+	Signal string `json:"signal"`
+}
+
+// decodeControlFrame tries to parse message as a controlFrame, reporting ok
+// only if it decodes as JSON and actually sets one of the recognized
+// fields; anything else (plain keystrokes that happen not to be JSON, or
+// JSON with no resize/signal) is left for the caller to treat as stdin.
+func decodeControlFrame(message []byte) (controlFrame, bool) {
+	var ctrl controlFrame
+	if json.Unmarshal(message, &ctrl) != nil {
+		return controlFrame{}, false
+	}
+
+	if ctrl.Resize == nil && ctrl.Signal == "" {
+		return controlFrame{}, false
+	}
+
+	return ctrl, true
+}
+
+// isTerminalRequest tells whether the client asked for an interactive
+// terminal, either via the "terminal.v1" subprotocol or the "mode=terminal"
+// query parameter, for clients that can't negotiate subprotocols.
+func isTerminalRequest(r *http.Request) bool {
+	if r.URL.Query().Get("mode") == "terminal" {
+		return true
+	}
+
+	for _, proto := range websocket.Subprotocols(r) {
+		if proto == terminalSubprotocol {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runTerminal allocates a pty for cmd, wires it up to conn and blocks until
+// the process exits or the connection is closed. Unlike the buffered mode
+// used by command, output is streamed to the client as soon as it is
+// produced instead of being polled every 100ms. outputLimit mirrors Job's
+// output byte cap: once the session has sent that many bytes to the
+// client, further pty output is read (so the child never blocks on a full
+// pty buffer) but dropped instead of being forwarded.
+func runTerminal(conn *SafeConn, cmd *exec.Cmd, toHTML bool, outputLimit int64) (int, error) {
+	f, err := pty.Start(cmd)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer f.Close()
+
+	// Reads from the pty and pushes frames into the connection's write pump.
+	// This replaces the polling loop in command with a blocking read that
+	// returns as soon as the child writes anything.
+	done := make(chan error, 1)
+	go func() {
+		var sent int64
+		var ansi ansiToHTMLConverter
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := f.Read(buf)
+			if n > 0 && (outputLimit <= 0 || sent < outputLimit) {
+				// Copied, since WriteMessage only queues the slice and buf
+				// is reused by the next Read.
+				raw := append([]byte(nil), buf[:n]...)
+				if outputLimit > 0 && sent+int64(len(raw)) > outputLimit {
+					raw = raw[:outputLimit-sent]
+				}
+
+				out := raw
+				if toHTML {
+					out = ansi.convert(raw)
+				}
+
+				if werr := conn.WriteMessage(websocket.BinaryMessage, out); werr != nil {
+					done <- werr
+					return
+				}
+				sent += int64(len(raw))
+			}
+
+			if err != nil {
+				if toHTML {
+					if rest := ansi.flush(); len(rest) > 0 {
+						_ = conn.WriteMessage(websocket.BinaryMessage, rest)
+					}
+				}
+				if err == io.EOF {
+					err = nil
+				}
+				done <- err
+				return
+			}
+		}
+	}()
+
+	// Forwards inbound frames. The control protocol (resize/signal) is
+	// accepted on either frame type, since browsers send JSON.stringify'd
+	// messages as TextMessage rather than BinaryMessage; anything that
+	// isn't a recognized control frame is forwarded to the pty as stdin.
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			if ctrl, ok := decodeControlFrame(message); ok {
+				if ctrl.Resize != nil {
+					_ = pty.Setsize(f, &pty.Winsize{
+						Rows: ctrl.Resize.Rows,
+						Cols: ctrl.Resize.Cols,
+					})
+				}
+
+				if ctrl.Signal == "SIGINT" {
+					_ = cmd.Process.Signal(syscall.SIGINT)
+				}
+
+				continue
+			}
+
+			if _, err := f.Write(message); err != nil {
+				return
+			}
+		}
+	}()
+
+	err = <-done
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	_ = cmd.Wait()
+	return 0, nil
+}
+
+// ansiToHTMLConverter converts pty output containing ANSI escape sequences
+// into HTML spans with inline styles, so that clients without a full
+// terminal emulator (e.g. no xterm.js) still get colored, formatted
+// output. It carries state across calls (zero value is ready to use)
+// because the pty is read in fixed-size chunks, so a single escape
+// sequence can legitimately land split across two reads.
+type ansiToHTMLConverter struct {
+	open    bool   // an unclosed <span> from a previous convert call
+	partial string // a \x1b[... sequence that hadn't reached its final byte yet
+}
+
+// maxPartialSeq bounds how long convert will keep buffering an
+// unterminated CSI sequence while waiting for its final byte. Real CSI
+// codes are a handful of bytes; a pty that emits "\x1b[" followed by an
+// endless run with no final byte (garbled output, or a hostile process)
+// would otherwise grow partial without bound.
+const maxPartialSeq = 4096
+
+// convert renders one chunk of raw pty output as HTML. Escape sequences it
+// doesn't recognize are dropped rather than echoed raw.
+func (c *ansiToHTMLConverter) convert(b []byte) []byte {
+	var out strings.Builder
+
+	s := c.partial + string(b)
+	c.partial = ""
+
+	for len(s) > 0 {
+		idx := strings.IndexByte(s, '\x1b')
+		if idx < 0 {
+			out.WriteString(html.EscapeString(s))
+			break
+		}
+
+		out.WriteString(html.EscapeString(s[:idx]))
+		s = s[idx:]
+
+		if len(s) < 2 {
+			// Not enough bytes yet to tell if this is even a CSI escape;
+			// wait for the rest to show up on the next read.
+			c.partial = s
+			break
+		}
+		if s[1] != '[' {
+			// Not a CSI sequence; drop the escape byte and move on.
+			s = s[1:]
+			continue
+		}
+
+		// A CSI sequence ends at its first byte in 0x40-0x7e; only SGR
+		// codes (final byte 'm') are rendered, the rest are dropped.
+		end := -1
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			if len(s) > maxPartialSeq {
+				// This has gone on far longer than any real CSI code
+				// would; give up waiting for its final byte and drop the
+				// escape byte instead of buffering unboundedly.
+				s = s[1:]
+				continue
+			}
+
+			// The sequence is cut off mid-read; stash it and pick up where
+			// we left off once more bytes arrive.
+			c.partial = s
+			break
+		}
+
+		codes := s[2:end]
+		final := s[end]
+		s = s[end+1:]
+
+		if final != 'm' {
+			continue
+		}
+
+		if c.open {
+			out.WriteString("</span>")
+			c.open = false
+		}
+
+		var classes []string
+		for _, code := range strings.Split(codes, ";") {
+			if class, ok := ansiClasses[code]; ok {
+				classes = append(classes, class)
+			}
+		}
+
+		if len(classes) > 0 {
+			out.WriteString(`<span class="` + strings.Join(classes, " ") + `">`)
+			c.open = true
+		}
+	}
+
+	return []byte(out.String())
+}
+
+// flush closes out a <span> left open by the most recent convert call, so
+// the final fragment sent to the client is always well-formed HTML even
+// if the pty closes (or the process exits) without ever emitting a
+// trailing reset code. Returns nil if nothing is open.
+func (c *ansiToHTMLConverter) flush() []byte {
+	if !c.open {
+		return nil
+	}
+
+	c.open = false
+	return []byte("</span>")
+}
+
+// ansiClasses maps a subset of SGR codes to CSS classes used by the bundled
+// terminal stylesheet on the frontend.
+var ansiClasses = map[string]string{
+	"1":  "ansi-bold",
+	"30": "ansi-black",
+	"31": "ansi-red",
+	"32": "ansi-green",
+	"33": "ansi-yellow",
+	"34": "ansi-blue",
+	"35": "ansi-magenta",
+	"36": "ansi-cyan",
+	"37": "ansi-white",
+}
+
+// terminalEnv returns the environment the shell process should inherit,
+// making sure TERM is set so full-screen programs like vim and top render
+// correctly inside the pty.
+func terminalEnv() []string {
+	env := os.Environ()
+	return append(env, "TERM=xterm-256color")
+}
@@ -0,0 +1,110 @@
+package vcs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unifiedDiff produces a unified-style diff between two whole-file
+// contents, computed from an actual line-level longest-common-subsequence
+// comparison rather than a blind "delete everything, add everything"
+// placeholder. It isn't trying to match git/hg/svn's diff algorithm
+// exactly (no diff minimization heuristics, no configurable context-line
+// count) — it's a fallback so every provider can return something
+// diff-shaped even when the underlying command doesn't hand back unified
+// diff text directly; used today by Git's working-tree-vs-HEAD comparison.
+func unifiedDiff(before, after string) string {
+	if before == after {
+		return ""
+	}
+
+	beforeLines := splitLines(before)
+	afterLines := splitLines(after)
+	ops := diffLines(beforeLines, afterLines)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -1,%d +1,%d @@\n", len(beforeLines), len(afterLines))
+	for _, op := range ops {
+		switch op.kind {
+		case diffEqual:
+			b.WriteString(" " + op.line + "\n")
+		case diffDelete:
+			b.WriteString("-" + op.line + "\n")
+		case diffInsert:
+			b.WriteString("+" + op.line + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+type diffOpKind int
+
+const (
+	diffEqual diffOpKind = iota
+	diffDelete
+	diffInsert
+)
+
+type diffOp struct {
+	kind diffOpKind
+	line string
+}
+
+// diffLines computes a line-level edit script turning before into after,
+// based on their longest common subsequence: lines in the LCS are kept as
+// context, everything else is a delete (before-only) or insert
+// (after-only) line.
+func diffLines(before, after []string) []diffOp {
+	n, m := len(before), len(after)
+
+	// lcs[i][j] holds the LCS length of before[i:] and after[j:].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case before[i] == after[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case before[i] == after[j]:
+			ops = append(ops, diffOp{diffEqual, before[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{diffDelete, before[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{diffInsert, after[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{diffDelete, before[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{diffInsert, after[j]})
+	}
+
+	return ops
+}
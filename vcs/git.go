@@ -0,0 +1,273 @@
+package vcs
+
+import (
+	"errors"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Git implements Provider on top of go-git, so status/log/diff don't
+// depend on a git binary being on PATH.
+type Git struct {
+	root string
+	repo *git.Repository
+}
+
+// NewGit opens the repository rooted at dir. Opening is deferred to first
+// use in case the directory isn't actually a valid repo yet.
+func NewGit(dir string) *Git {
+	return &Git{root: dir}
+}
+
+func (g *Git) open() (*git.Repository, error) {
+	if g.repo != nil {
+		return g.repo, nil
+	}
+
+	repo, err := git.PlainOpen(g.root)
+	if err != nil {
+		return nil, err
+	}
+
+	g.repo = repo
+	return repo, nil
+}
+
+func (g *Git) Name() string { return "git" }
+
+func (g *Git) Status() ([]FileStatus, error) {
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]FileStatus, 0, len(st))
+	for path, s := range st {
+		out = append(out, FileStatus{Path: path, Status: string(statusCode(s))})
+	}
+
+	return out, nil
+}
+
+// statusCode picks the more interesting of a file's staged/unstaged codes,
+// matching the single-letter summary "git status --short" prints.
+func statusCode(s *git.FileStatus) byte {
+	if s.Staging != git.Unmodified {
+		return byte(s.Staging)
+	}
+	return byte(s.Worktree)
+}
+
+func (g *Git) Log(limit int) ([]Commit, error) {
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	err = iter.ForEach(func(c *object.Commit) error {
+		if limit > 0 && len(commits) >= limit {
+			return errStopIteration
+		}
+
+		parents := make([]string, 0, c.NumParents())
+		c.Parents().ForEach(func(p *object.Commit) error {
+			parents = append(parents, p.Hash.String())
+			return nil
+		})
+
+		commits = append(commits, Commit{
+			Hash:    c.Hash.String(),
+			Parents: parents,
+			Author:  c.Author.Name,
+			Date:    c.Author.When.Format("2006-01-02T15:04:05Z07:00"),
+			Message: c.Message,
+		})
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopIteration) {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// errStopIteration breaks out of object.CommitIter.ForEach once the caller
+// supplied limit is reached.
+var errStopIteration = errors.New("stop iteration")
+
+func (g *Git) Diff(path string) (*Diff, error) {
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	headFile, _ := headCommit.File(path)
+	var headContent string
+	if headFile != nil {
+		headContent, _ = headFile.Contents()
+	}
+
+	wtFile, err := wt.Filesystem.Open(path)
+	if err != nil {
+		return &Diff{Path: path, Text: unifiedDiff(headContent, "")}, nil
+	}
+	defer wtFile.Close()
+
+	buf := make([]byte, 0, 4096)
+	chunk := make([]byte, 4096)
+	for {
+		n, err := wtFile.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil {
+			break
+		}
+	}
+
+	return &Diff{Path: path, Text: unifiedDiff(headContent, string(buf))}, nil
+}
+
+func (g *Git) Commit(message string, paths []string) (*Commit, error) {
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		if _, err := wt.Add(p); err != nil {
+			return nil, err
+		}
+	}
+
+	hash, err := wt.Commit(message, &git.CommitOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Commit{
+		Hash:    c.Hash.String(),
+		Author:  c.Author.Name,
+		Date:    c.Author.When.Format("2006-01-02T15:04:05Z07:00"),
+		Message: c.Message,
+	}, nil
+}
+
+func (g *Git) Branches() ([]Branch, error) {
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []Branch
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		branches = append(branches, Branch{
+			Name:    ref.Name().Short(),
+			Current: ref.Name() == head.Name(),
+		})
+		return nil
+	})
+
+	return branches, err
+}
+
+func (g *Git) Checkout(branch string) error {
+	repo, err := g.open()
+	if err != nil {
+		return err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+	})
+}
+
+func (g *Git) Pull() error {
+	repo, err := g.open()
+	if err != nil {
+		return err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	err = wt.Pull(&git.PullOptions{})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+
+	return err
+}
+
+func (g *Git) Push() error {
+	repo, err := g.open()
+	if err != nil {
+		return err
+	}
+
+	return repo.Push(&git.PushOptions{})
+}
@@ -0,0 +1,155 @@
+package vcs
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Mercurial implements Provider by shelling out to the hg binary and
+// parsing its (machine-friendly, --template driven) output into
+// structured types, since there's no mature pure-Go mercurial library.
+type Mercurial struct {
+	root string
+}
+
+// NewMercurial wraps the repository rooted at dir.
+func NewMercurial(dir string) *Mercurial {
+	return &Mercurial{root: dir}
+}
+
+func (m *Mercurial) run(args ...string) (string, error) {
+	cmd := exec.Command("hg", args...)
+	cmd.Dir = m.root
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("hg %s: %w: %s", strings.Join(args, " "), err, out.String())
+	}
+
+	return out.String(), nil
+}
+
+func (m *Mercurial) Name() string { return "hg" }
+
+func (m *Mercurial) Status() ([]FileStatus, error) {
+	out, err := m.run("status")
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []FileStatus
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if len(line) < 3 {
+			continue
+		}
+		statuses = append(statuses, FileStatus{Status: line[:1], Path: line[2:]})
+	}
+
+	return statuses, nil
+}
+
+func (m *Mercurial) Log(limit int) ([]Commit, error) {
+	args := []string{"log", "--template", "{node}\\x01{p1node}\\x01{author}\\x01{date|rfc3339date}\\x01{desc}\\x02"}
+	if limit > 0 {
+		args = append(args, "--limit", fmt.Sprint(limit))
+	}
+
+	out, err := m.run(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, entry := range strings.Split(out, "\x02") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, "\x01")
+		if len(fields) != 5 {
+			continue
+		}
+
+		var parents []string
+		if fields[1] != "" {
+			parents = []string{fields[1]}
+		}
+
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Parents: parents,
+			Author:  fields[2],
+			Date:    fields[3],
+			Message: fields[4],
+		})
+	}
+
+	return commits, nil
+}
+
+func (m *Mercurial) Diff(path string) (*Diff, error) {
+	out, err := m.run("diff", "--", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Diff{Path: path, Text: out}, nil
+}
+
+func (m *Mercurial) Commit(message string, paths []string) (*Commit, error) {
+	args := append([]string{"commit", "-m", message, "--"}, paths...)
+	if _, err := m.run(args...); err != nil {
+		return nil, err
+	}
+
+	commits, err := m.Log(1)
+	if err != nil || len(commits) == 0 {
+		return nil, err
+	}
+
+	return &commits[0], nil
+}
+
+func (m *Mercurial) Branches() ([]Branch, error) {
+	out, err := m.run("bookmarks")
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []Branch
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		current := strings.HasPrefix(line, "*")
+		line = strings.TrimPrefix(line, "*")
+		name := strings.Fields(strings.TrimSpace(line))[0]
+
+		branches = append(branches, Branch{Name: name, Current: current})
+	}
+
+	return branches, nil
+}
+
+func (m *Mercurial) Checkout(branch string) error {
+	_, err := m.run("update", "--", branch)
+	return err
+}
+
+func (m *Mercurial) Pull() error {
+	_, err := m.run("pull", "-u")
+	return err
+}
+
+func (m *Mercurial) Push() error {
+	_, err := m.run("push")
+	return err
+}
@@ -0,0 +1,198 @@
+package vcs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Subversion implements Provider by shelling out to the svn binary. Status
+// and log are parsed from svn's --xml output rather than scraping the
+// human-readable format.
+type Subversion struct {
+	root string
+}
+
+// NewSubversion wraps the working copy rooted at dir.
+func NewSubversion(dir string) *Subversion {
+	return &Subversion{root: dir}
+}
+
+func (s *Subversion) run(args ...string) (string, error) {
+	cmd := exec.Command("svn", args...)
+	cmd.Dir = s.root
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("svn %s: %w: %s", strings.Join(args, " "), err, out.String())
+	}
+
+	return out.String(), nil
+}
+
+func (s *Subversion) Name() string { return "svn" }
+
+type svnStatusXML struct {
+	Target struct {
+		Entries []struct {
+			Path     string `xml:"path,attr"`
+			WCStatus struct {
+				Item string `xml:"item,attr"`
+			} `xml:"wc-status"`
+		} `xml:"entry"`
+	} `xml:"target"`
+}
+
+func (s *Subversion) Status() ([]FileStatus, error) {
+	out, err := s.run("status", "--xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed svnStatusXML
+	if err := xml.Unmarshal([]byte(out), &parsed); err != nil {
+		return nil, err
+	}
+
+	// --xml spells each status out; map it to the letter "svn status"
+	// prints, keeping unknown items as they are.
+	letters := map[string]string{
+		"added": "A", "conflicted": "C", "deleted": "D", "ignored": "I",
+		"modified": "M", "replaced": "R", "external": "X",
+		"unversioned": "?", "missing": "!", "obstructed": "~",
+	}
+
+	statuses := make([]FileStatus, 0, len(parsed.Target.Entries))
+	for _, e := range parsed.Target.Entries {
+		code, ok := letters[e.WCStatus.Item]
+		if !ok {
+			code = e.WCStatus.Item
+		}
+		statuses = append(statuses, FileStatus{Path: e.Path, Status: code})
+	}
+
+	return statuses, nil
+}
+
+type svnLogXML struct {
+	Entries []struct {
+		Revision string `xml:"revision,attr"`
+		Author   string `xml:"author"`
+		Date     string `xml:"date"`
+		Msg      string `xml:"msg"`
+	} `xml:"logentry"`
+}
+
+func (s *Subversion) Log(limit int) ([]Commit, error) {
+	args := []string{"log", "--xml"}
+	if limit > 0 {
+		args = append(args, "-l", fmt.Sprint(limit))
+	}
+
+	out, err := s.run(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed svnLogXML
+	if err := xml.Unmarshal([]byte(out), &parsed); err != nil {
+		return nil, err
+	}
+
+	commits := make([]Commit, 0, len(parsed.Entries))
+	for _, e := range parsed.Entries {
+		commits = append(commits, Commit{
+			Hash:    "r" + e.Revision,
+			Author:  e.Author,
+			Date:    e.Date,
+			Message: e.Msg,
+		})
+	}
+
+	return commits, nil
+}
+
+func (s *Subversion) Diff(path string) (*Diff, error) {
+	out, err := s.run("diff", "--", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Diff{Path: path, Text: out}, nil
+}
+
+func (s *Subversion) Commit(message string, paths []string) (*Commit, error) {
+	args := append([]string{"commit", "-m", message, "--"}, paths...)
+	if _, err := s.run(args...); err != nil {
+		return nil, err
+	}
+
+	commits, err := s.Log(1)
+	if err != nil || len(commits) == 0 {
+		return nil, err
+	}
+
+	return &commits[0], nil
+}
+
+// Branches lists the directories under branches/, since svn has no native
+// branch concept; "current" is best-effort based on the working copy URL.
+func (s *Subversion) Branches() ([]Branch, error) {
+	info, err := s.run("info")
+	if err != nil {
+		return nil, err
+	}
+
+	var currentURL string
+	for _, line := range strings.Split(info, "\n") {
+		if strings.HasPrefix(line, "URL: ") {
+			currentURL = strings.TrimPrefix(line, "URL: ")
+		}
+	}
+
+	out, err := s.run("list", "^/branches")
+	if err != nil {
+		return []Branch{{Name: "trunk", Current: true}}, nil
+	}
+
+	branches := []Branch{{Name: "trunk", Current: strings.HasSuffix(currentURL, "/trunk")}}
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		name := strings.TrimSuffix(strings.TrimSpace(line), "/")
+		if name == "" {
+			continue
+		}
+
+		branches = append(branches, Branch{
+			Name:    name,
+			Current: strings.Contains(currentURL, "/branches/"+name),
+		})
+	}
+
+	return branches, nil
+}
+
+func (s *Subversion) Checkout(branch string) error {
+	url := "^/trunk"
+	if branch != "trunk" {
+		url = "^/branches/" + branch
+	}
+
+	_, err := s.run("switch", "--", url)
+	return err
+}
+
+func (s *Subversion) Pull() error {
+	_, err := s.run("update")
+	return err
+}
+
+// Push is a no-op for svn: "commit" already publishes to the central
+// repository, there's no separate push step.
+func (s *Subversion) Push() error {
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package vcs abstracts over the version control systems filebrowser can
+// shell out to, so the frontend can render a structured repo view instead
+// of raw terminal output.
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStatus is the status of a single file relative to HEAD/tip, using a
+// one-letter code similar to "git status --short".
+type FileStatus struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // "M", "A", "D", "R", "?", ...
+}
+
+// Commit is a single entry in a repo's history.
+type Commit struct {
+	Hash    string   `json:"hash"`
+	Parents []string `json:"parents"`
+	Author  string   `json:"author"`
+	Date    string   `json:"date"`
+	Message string   `json:"message"`
+}
+
+// Diff is the textual diff for one path, unified-diff formatted.
+type Diff struct {
+	Path string `json:"path"`
+	Text string `json:"text"`
+}
+
+// Branch describes a single branch/bookmark head.
+type Branch struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
+
+// Provider is implemented by each supported VCS. Every method is scoped to
+// the repo rooted at the path the Provider was created with.
+type Provider interface {
+	// Name identifies the provider, e.g. "git", "hg", "svn".
+	Name() string
+
+	Status() ([]FileStatus, error)
+	Log(limit int) ([]Commit, error)
+	Diff(path string) (*Diff, error)
+	Commit(message string, paths []string) (*Commit, error)
+	Branches() ([]Branch, error)
+	Checkout(branch string) error
+	Pull() error
+	Push() error
+}
+
+// Detect walks up from dir, no higher than root, looking for a .git, .hg
+// or .svn directory, and returns a Provider rooted at the repo it finds,
+// or nil if no such repository exists at or above dir within root. root
+// should be the user's jailed filesystem root: without this boundary a
+// user with no repo of their own could have a provider resolved against
+// whatever repository happens to live above their filesystem on the host.
+func Detect(dir, root string) (Provider, error) {
+	root = filepath.Clean(root)
+	dir = filepath.Clean(dir)
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		// dir isn't under root at all; nothing to detect.
+		return nil, nil
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && info.IsDir() {
+			return NewGit(dir), nil
+		}
+
+		if info, err := os.Stat(filepath.Join(dir, ".hg")); err == nil && info.IsDir() {
+			return NewMercurial(dir), nil
+		}
+
+		if info, err := os.Stat(filepath.Join(dir, ".svn")); err == nil && info.IsDir() {
+			return NewSubversion(dir), nil
+		}
+
+		if dir == root {
+			return nil, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
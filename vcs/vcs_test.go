@@ -0,0 +1,88 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFindsRepoAtDir(t *testing.T) {
+	root := t.TempDir()
+	mustMkdir(t, filepath.Join(root, ".git"))
+
+	provider, err := Detect(root, root)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if provider == nil || provider.Name() != "git" {
+		t.Fatalf("Detect(%q, %q) = %v, want a git provider", root, root, provider)
+	}
+}
+
+func TestDetectWalksUpWithinRoot(t *testing.T) {
+	root := t.TempDir()
+	mustMkdir(t, filepath.Join(root, ".hg"))
+	sub := filepath.Join(root, "a", "b", "c")
+	mustMkdir(t, sub)
+
+	provider, err := Detect(sub, root)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if provider == nil || provider.Name() != "hg" {
+		t.Fatalf("Detect(%q, %q) = %v, want an hg provider", sub, root, provider)
+	}
+}
+
+func TestDetectStopsAtRootBoundary(t *testing.T) {
+	// The repo marker lives above root, so Detect must not walk past root
+	// to find it.
+	outer := t.TempDir()
+	mustMkdir(t, filepath.Join(outer, ".svn"))
+	root := filepath.Join(outer, "jail")
+	sub := filepath.Join(root, "work")
+	mustMkdir(t, sub)
+
+	provider, err := Detect(sub, root)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if provider != nil {
+		t.Fatalf("Detect(%q, %q) = %v, want nil (repo is above root)", sub, root, provider)
+	}
+}
+
+func TestDetectNoRepoFound(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "work")
+	mustMkdir(t, sub)
+
+	provider, err := Detect(sub, root)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if provider != nil {
+		t.Fatalf("Detect(%q, %q) = %v, want nil (no repo anywhere)", sub, root, provider)
+	}
+}
+
+func TestDetectDirOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	mustMkdir(t, filepath.Join(outside, ".git"))
+
+	provider, err := Detect(outside, root)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if provider != nil {
+		t.Fatalf("Detect(%q, %q) = %v, want nil (dir not under root)", outside, root, provider)
+	}
+}
+
+func mustMkdir(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir %q: %v", dir, err)
+	}
+}
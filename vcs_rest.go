@@ -0,0 +1,139 @@
+package filemanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"vungoccuong/filebrowser/vcs"
+)
+
+// providerFor auto-detects the VCS rooted somewhere above r.URL.Path
+// within the user's filesystem, and gates it on the existing command
+// allowlist: a provider is only returned if its name ("git", "hg", "svn")
+// is also present in c.User.Commands.
+func providerFor(c *RequestContext, r *http.Request) (vcs.Provider, error) {
+	root := string(c.User.FileSystem)
+	dir := filepath.Join(root, r.URL.Path)
+
+	provider, err := vcs.Detect(dir, root)
+	if err != nil || provider == nil {
+		return nil, err
+	}
+
+	for _, cmd := range c.User.Commands {
+		if cmd == provider.Name() {
+			return provider, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// vcsHandler adapts a vcs.Provider method into the package's
+// (int, error) handler signature, writing its result as JSON.
+func vcsHandler(fn func(vcs.Provider, *http.Request) (interface{}, error)) func(*RequestContext, http.ResponseWriter, *http.Request) (int, error) {
+	return func(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+		provider, err := providerFor(c, r)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if provider == nil {
+			return http.StatusNotFound, nil
+		}
+
+		result, err := fn(provider, r)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		return http.StatusOK, json.NewEncoder(w).Encode(result)
+	}
+}
+
+// vcsStatusHandler handles GET /api/vcs/status.
+var vcsStatusHandler = vcsHandler(func(p vcs.Provider, r *http.Request) (interface{}, error) {
+	return p.Status()
+})
+
+// vcsLogHandler handles GET /api/vcs/log.
+var vcsLogHandler = vcsHandler(func(p vcs.Provider, r *http.Request) (interface{}, error) {
+	return p.Log(100)
+})
+
+// vcsDiffHandler handles GET /api/vcs/diff?path=....
+var vcsDiffHandler = vcsHandler(func(p vcs.Provider, r *http.Request) (interface{}, error) {
+	return p.Diff(r.URL.Query().Get("path"))
+})
+
+// vcsBranchesHandler handles GET /api/vcs/branches.
+var vcsBranchesHandler = vcsHandler(func(p vcs.Provider, r *http.Request) (interface{}, error) {
+	return p.Branches()
+})
+
+// vcsCommitHandler handles POST /api/vcs/commit, with the message and
+// comma-separated paths passed as query parameters to match the rest of
+// this package's simple query-param-driven endpoints.
+var vcsCommitHandler = vcsHandler(func(p vcs.Provider, r *http.Request) (interface{}, error) {
+	message := r.URL.Query().Get("message")
+
+	var paths []string
+	if raw := r.URL.Query().Get("paths"); raw != "" {
+		paths = strings.Split(raw, ",")
+	}
+
+	return p.Commit(message, paths)
+})
+
+// vcsCheckoutHandler handles POST /api/vcs/checkout?branch=....
+func vcsCheckoutHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	provider, err := providerFor(c, r)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if provider == nil {
+		return http.StatusNotFound, nil
+	}
+
+	if err := provider.Checkout(r.URL.Query().Get("branch")); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// vcsPullHandler handles POST /api/vcs/pull.
+func vcsPullHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	provider, err := providerFor(c, r)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if provider == nil {
+		return http.StatusNotFound, nil
+	}
+
+	if err := provider.Pull(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// vcsPushHandler handles POST /api/vcs/push.
+func vcsPushHandler(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	provider, err := providerFor(c, r)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if provider == nil {
+		return http.StatusNotFound, nil
+	}
+
+	if err := provider.Push(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
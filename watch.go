@@ -0,0 +1,382 @@
+package filemanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+// watchEvent is what gets pushed to subscribed clients, coalescing
+// create+write+rename bursts into one logical notification.
+type watchEvent struct {
+	Op      string `json:"op"` // "create", "write", "remove", "rename", "chmod"
+	Path    string `json:"path"`
+	IsDir   bool   `json:"isDir"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modtime"`
+}
+
+// watchDebounce is the coalescing window: rapid bursts of events for the
+// same path (e.g. during a large copy or `git checkout`) collapse into a
+// single dispatched event.
+const watchDebounce = 100 * time.Millisecond
+
+// watchSubscriber is a single /api/watch connection's registration with
+// the hub.
+type watchSubscriber struct {
+	conn    *SafeConn
+	scope   string
+	allowed func(string) bool
+
+	// dirs is every directory under scope the hub added a watch for on
+	// this subscriber's behalf, recorded so unsubscribe can release its
+	// share of each one.
+	dirs []string
+}
+
+// watchHub maintains one shared fsnotify.Watcher per server root and
+// fans coalesced events out to every registered subscriber whose scope
+// and ACL match, instead of each connection running its own watcher.
+type watchHub struct {
+	mu          sync.Mutex
+	watcher     *fsnotify.Watcher
+	watchedDirs map[string]int // dir -> number of subscribers relying on it
+	subs        map[*watchSubscriber]bool
+
+	pending map[string]*pendingEvent
+}
+
+type pendingEvent struct {
+	event watchEvent
+	timer *time.Timer
+}
+
+// hub is the process-wide fsnotify fan-out used by /api/watch.
+var hub = newWatchHub()
+
+func newWatchHub() *watchHub {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		// Nothing can be watched without a working fsnotify instance;
+		// callers will just get no events rather than a crash.
+		return &watchHub{watchedDirs: map[string]int{}, subs: map[*watchSubscriber]bool{}, pending: map[string]*pendingEvent{}}
+	}
+
+	h := &watchHub{
+		watcher:     w,
+		watchedDirs: map[string]int{},
+		subs:        map[*watchSubscriber]bool{},
+		pending:     map[string]*pendingEvent{},
+	}
+
+	go h.loop()
+	return h
+}
+
+func (h *watchHub) loop() {
+	if h.watcher == nil {
+		return
+	}
+
+	for {
+		select {
+		case ev, ok := <-h.watcher.Events:
+			if !ok {
+				return
+			}
+			h.schedule(ev)
+
+		case <-h.watcher.Errors:
+			// Best-effort: a watcher error doesn't take down the hub, the
+			// next successful event keeps subscribers converging.
+		}
+	}
+}
+
+// schedule debounces ev behind a per-path timer, so a flurry of
+// create/write/rename events for the same file collapse into a single
+// dispatch.
+func (h *watchHub) schedule(ev fsnotify.Event) {
+	info, statErr := os.Stat(ev.Name)
+
+	we := watchEvent{
+		Op:   opName(ev.Op),
+		Path: filepath.ToSlash(ev.Name),
+	}
+	if statErr == nil {
+		we.IsDir = info.IsDir()
+		we.Size = info.Size()
+		we.ModTime = info.ModTime().Unix()
+	}
+
+	if we.IsDir && ev.Op&(fsnotify.Create) != 0 {
+		h.adoptNewDir(ev.Name)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if p, ok := h.pending[ev.Name]; ok {
+		p.event = we
+		p.timer.Reset(watchDebounce)
+		return
+	}
+
+	h.pending[ev.Name] = &pendingEvent{
+		event: we,
+		timer: time.AfterFunc(watchDebounce, func() { h.flush(ev.Name) }),
+	}
+}
+
+func (h *watchHub) flush(path string) {
+	h.mu.Lock()
+	p, ok := h.pending[path]
+	if ok {
+		delete(h.pending, path)
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	h.dispatch(p.event)
+}
+
+func (h *watchHub) dispatch(ev watchEvent) {
+	encoded, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	subs := make([]*watchSubscriber, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.mu.Unlock()
+
+	for _, s := range subs {
+		rel, ok := withinScope(ev.Path, s.scope)
+		if !ok {
+			continue
+		}
+
+		if s.allowed != nil && !s.allowed(rel) {
+			continue
+		}
+
+		s.conn.WriteMessage(websocket.TextMessage, encoded)
+	}
+}
+
+// withinScope reports whether path is scope itself or a descendant of it,
+// returning path's slash-separated position relative to scope. A bare
+// strings.HasPrefix would also match sibling paths that merely share a
+// string prefix (scope "/a/b" matching "/a/bc"); this requires an exact
+// match or a path-separator boundary right after scope.
+func withinScope(path, scope string) (string, bool) {
+	if path == scope {
+		return "", true
+	}
+
+	if strings.HasPrefix(path, scope+"/") {
+		return strings.TrimPrefix(path, scope+"/"), true
+	}
+
+	return "", false
+}
+
+func opName(op fsnotify.Op) string {
+	switch {
+	case op&fsnotify.Create != 0:
+		return "create"
+	case op&fsnotify.Remove != 0:
+		return "remove"
+	case op&fsnotify.Rename != 0:
+		return "rename"
+	case op&fsnotify.Write != 0:
+		return "write"
+	default:
+		return "chmod"
+	}
+}
+
+// ensureWatched makes sure dir has a live fsnotify watch, adding one (and
+// a zeroed refcount entry) the first time it's seen.
+func (h *watchHub) ensureWatched(dir string) {
+	h.mu.Lock()
+	_, exists := h.watchedDirs[dir]
+	h.mu.Unlock()
+	if exists || h.watcher == nil {
+		return
+	}
+
+	if err := h.watcher.Add(dir); err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	h.watchedDirs[dir] = 0
+	h.mu.Unlock()
+}
+
+// ref increments dir's subscriber count, watching it first if needed.
+func (h *watchHub) ref(dir string) {
+	h.ensureWatched(dir)
+
+	h.mu.Lock()
+	h.watchedDirs[dir]++
+	h.mu.Unlock()
+}
+
+// unref decrements dir's subscriber count, removing the underlying
+// fsnotify watch once nothing references it anymore.
+func (h *watchHub) unref(dir string) {
+	h.mu.Lock()
+	n, ok := h.watchedDirs[dir]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+
+	n--
+	if n <= 0 {
+		delete(h.watchedDirs, dir)
+	} else {
+		h.watchedDirs[dir] = n
+	}
+	h.mu.Unlock()
+
+	if n <= 0 && h.watcher != nil {
+		h.watcher.Remove(dir)
+	}
+}
+
+// walkDirs collects dir and every subdirectory beneath it.
+func walkDirs(dir string) []string {
+	dirs := []string{dir}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return dirs
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, walkDirs(filepath.Join(dir, e.Name()))...)
+		}
+	}
+
+	return dirs
+}
+
+// subscribe registers sub with the hub, watching its whole scope tree and
+// recording every directory it took a reference on so unsubscribe can let
+// them go again.
+func (h *watchHub) subscribe(sub *watchSubscriber) {
+	sub.dirs = walkDirs(sub.scope)
+	for _, d := range sub.dirs {
+		h.ref(d)
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = true
+	h.mu.Unlock()
+}
+
+// unsubscribe removes sub and releases its references on every directory
+// it was watching.
+func (h *watchHub) unsubscribe(sub *watchSubscriber) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	dirs := sub.dirs
+	sub.dirs = nil
+	h.mu.Unlock()
+
+	for _, d := range dirs {
+		h.unref(d)
+	}
+}
+
+// adoptNewDir watches a directory that just appeared, on behalf of every
+// current subscriber whose scope covers it, so it gets cleaned up once
+// those subscribers disconnect instead of leaking for the life of the
+// process.
+func (h *watchHub) adoptNewDir(dir string) {
+	h.mu.Lock()
+	subs := make([]*watchSubscriber, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.mu.Unlock()
+
+	var owners []*watchSubscriber
+	for _, s := range subs {
+		if _, ok := withinScope(dir, s.scope); ok {
+			owners = append(owners, s)
+		}
+	}
+
+	h.mu.Lock()
+	for _, s := range owners {
+		s.dirs = append(s.dirs, dir)
+	}
+	h.mu.Unlock()
+
+	adopted := len(owners) > 0
+	for range owners {
+		h.ref(dir)
+	}
+
+	if !adopted {
+		return
+	}
+
+	// Recurse into dir's immediate children only: each recursive call
+	// handles its own subtree the same way, so descending into the full
+	// flattened walkDirs(dir) here would re-walk every deeper
+	// subdirectory once per ancestor already on the stack.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			h.adoptNewDir(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// watch handles GET /api/watch, subscribing the connection to filesystem
+// change events under the requested path for as long as it stays open.
+func watch(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	conn, err := NewSafeConn(w, r)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	scope := filepath.Join(string(c.User.FileSystem), r.URL.Path)
+	scope = filepath.Clean(scope)
+
+	sub := &watchSubscriber{conn: conn, scope: scope, allowed: c.User.Allowed}
+	hub.subscribe(sub)
+	defer hub.unsubscribe(sub)
+
+	// The connection has nothing to send the server; block until it's
+	// closed so the deferred unsubscribe runs.
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return 0, nil
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package filemanager
+
+import "testing"
+
+func TestWithinScopeExactMatch(t *testing.T) {
+	rel, ok := withinScope("/srv/data", "/srv/data")
+	if !ok || rel != "" {
+		t.Fatalf("withinScope(same path) = %q, %v, want \"\", true", rel, ok)
+	}
+}
+
+func TestWithinScopeDescendant(t *testing.T) {
+	rel, ok := withinScope("/srv/data/sub/file.txt", "/srv/data")
+	if !ok || rel != "sub/file.txt" {
+		t.Fatalf("withinScope(descendant) = %q, %v, want \"sub/file.txt\", true", rel, ok)
+	}
+}
+
+func TestWithinScopeRejectsSiblingWithSharedPrefix(t *testing.T) {
+	// "/a/bc" merely shares a string prefix with scope "/a/b"; it isn't a
+	// descendant and must not match.
+	rel, ok := withinScope("/a/bc", "/a/b")
+	if ok {
+		t.Fatalf("withinScope(sibling prefix) = %q, %v, want false", rel, ok)
+	}
+}
+
+func TestWithinScopeRejectsUnrelatedPath(t *testing.T) {
+	rel, ok := withinScope("/other/file.txt", "/srv/data")
+	if ok {
+		t.Fatalf("withinScope(unrelated) = %q, %v, want false", rel, ok)
+	}
+}
@@ -1,7 +1,8 @@
 package filemanager
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"os"
 	"os/exec"
@@ -10,11 +11,16 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"vungoccuong/filebrowser/search/index"
 )
 
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	ReadBufferSize:    4096,
+	WriteBufferSize:   4096,
+	CheckOrigin:       checkOrigin,
+	EnableCompression: true,
+	Subprotocols:      []string{terminalSubprotocol},
 }
 
 var (
@@ -25,7 +31,7 @@ var (
 // command handles the requests for VCS related commands: git, svn and mercurial
 func command(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
 	// Upgrades the connection to a websocket and checks for errors.
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := NewSafeConn(w, r)
 	if err != nil {
 		return 0, err
 	}
@@ -77,101 +83,102 @@ func command(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, er
 		return http.StatusNotImplemented, nil
 	}
 
-	// Gets the path and initializes a buffer.
+	// Gets the path.
 	path := string(c.User.FileSystem) + "/" + r.URL.Path
 	path = filepath.Clean(path)
-	buff := new(bytes.Buffer)
 
-	// Sets up the command executation.
-	cmd := exec.Command(command[0], command[1:]...)
-	cmd.Dir = path
-	cmd.Stderr = buff
-	cmd.Stdout = buff
+	// If the client opted into interactive mode, hand the rest of the
+	// connection over to a pty-backed session instead of a tracked Job.
+	// It still has to go through the same concurrency/timeout/output
+	// limits as a tracked Job, just applied directly since a pty session
+	// owns cmd's stdio and can't be wrapped in a Job the way command
+	// execution is below.
+	if isTerminalRequest(r) {
+		release, err := jobManager.Reserve(c)
+		if err != nil {
+			if err == ErrJobLimit {
+				return http.StatusTooManyRequests, nil
+			}
+			return http.StatusInternalServerError, err
+		}
+		defer release()
+
+		ctx := context.Background()
+		var cancel context.CancelFunc
+		if c.User.JobTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, c.User.JobTimeout)
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Dir = path
+		cmd.Env = terminalEnv()
+		applyResourceLimits(cmd, c.User)
+
+		toHTML := r.URL.Query().Get("render") == "html"
+		return runTerminal(conn, cmd, toHTML, c.User.MaxOutputBytes)
+	}
 
-	// Starts the command and checks for errors.
-	err = cmd.Start()
+	// Registers the command as a tracked, cancellable Job instead of a raw
+	// exec.Command, which also enforces the user's concurrency/timeout/
+	// output limits.
+	job, err := jobManager.Start(c, command, path)
 	if err != nil {
+		if err == ErrJobLimit {
+			return http.StatusTooManyRequests, nil
+		}
 		return http.StatusInternalServerError, err
 	}
 
-	// Set a 'done' variable to check whetever the command has already finished
-	// running or not. This verification is done using a goroutine that uses the
-	// method .Wait() from the command.
-	done := false
+	// Interrupt the job if the client sends a {"signal":"SIGINT"} control
+	// frame, and cancel it if the connection drops or if the request
+	// context itself is torn down (e.g. by a reverse proxy timing out the
+	// upgrade).
 	go func() {
-		err = cmd.Wait()
-		done = true
+		<-r.Context().Done()
+		jobManager.Cancel(job.ID)
 	}()
 
-	// Function to print the current information on the buffer to the connection.
-	print := func() error {
-		by := buff.Bytes()
-		if len(by) > 0 {
-			err = conn.WriteMessage(websocket.TextMessage, by)
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
 			if err != nil {
-				return err
+				jobManager.Cancel(job.ID)
+				return
 			}
-		}
 
-		return nil
-	}
+			var ctrl controlFrame
+			if json.Unmarshal(message, &ctrl) == nil && ctrl.Signal == "SIGINT" {
+				job.Signal(os.Interrupt)
+			}
+		}
+	}()
 
-	// While the command hasn't finished running, continue sending the output
-	// to the client in intervals of 100 milliseconds.
-	for !done {
-		if err = print(); err != nil {
+	// Streams the job's captured output to the client every 100ms until it
+	// exits, then one final time to flush anything written right at the end.
+	for !job.Done() {
+		if err := conn.WriteMessage(websocket.TextMessage, job.Output()); err != nil {
 			return http.StatusInternalServerError, err
 		}
 
 		time.Sleep(100 * time.Millisecond)
 	}
 
-	// After the command is done executing, send the output one more time to the
-	// browser to make sure it gets the latest information.
-	if err = print(); err != nil {
+	if err := conn.WriteMessage(websocket.TextMessage, job.Output()); err != nil {
 		return http.StatusInternalServerError, err
 	}
 
 	return 0, nil
 }
 
-type searchOptions struct {
-	CaseInsensitive bool
-	Terms           []string
-}
-
-func parseSearch(value string) *searchOptions {
-	opts := &searchOptions{
-		CaseInsensitive: strings.Contains(value, "case:insensitive"),
-	}
-
-	// removes the options from the value
-	value = strings.Replace(value, "case:insensitive", "", -1)
-	value = strings.Replace(value, "case:sensitive", "", -1)
-	value = strings.TrimSpace(value)
-
-	if opts.CaseInsensitive {
-		value = strings.ToLower(value)
-	}
-
-	// if the value starts with " and finishes what that character, we will
-	// only search for that term
-	if value[0] == '"' && value[len(value)-1] == '"' {
-		unique := strings.TrimPrefix(value, "\"")
-		unique = strings.TrimSuffix(unique, "\"")
-
-		opts.Terms = []string{unique}
-		return opts
-	}
-
-	opts.Terms = strings.Split(value, " ")
-	return opts
-}
-
-// search searches for a file or directory.
+// search runs the query against the content index, ranking matching files
+// by BM25 score, then sends each hit to the client as a JSON message, best
+// first.
 func search(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, error) {
 	// Upgrades the connection to a websocket and checks for errors.
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := NewSafeConn(w, r)
 	if err != nil {
 		return 0, err
 	}
@@ -179,7 +186,6 @@ func search(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, err
 
 	var (
 		value   string
-		search  *searchOptions
 		message []byte
 	)
 
@@ -196,46 +202,30 @@ func search(c *RequestContext, w http.ResponseWriter, r *http.Request) (int, err
 		}
 	}
 
-	search = parseSearch(value)
-	scope := strings.TrimPrefix(r.URL.Path, "/")
-	scope = "/" + scope
-	scope = string(c.User.FileSystem) + scope
-	scope = strings.Replace(scope, "\\", "/", -1)
-	scope = filepath.Clean(scope)
-
-	err = filepath.Walk(scope, func(path string, f os.FileInfo, err error) error {
-		if search.CaseInsensitive {
-			path = strings.ToLower(path)
-		}
+	idx, err := indexFor(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
-		path = strings.TrimPrefix(path, scope)
-		path = strings.TrimPrefix(path, "/")
-		path = strings.Replace(path, "\\", "/", -1)
-		is := false
+	q := index.ParseQuery(value, index.DefaultAnalyzer())
 
-		for _, term := range search.Terms {
-			if is {
-				break
-			}
+	hits, err := idx.Query(q, c.User.Allowed, 100)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
-			if strings.Contains(path, term) {
-				if !c.User.Allowed(path) {
-					return nil
-				}
+	root := string(c.User.FileSystem)
+	for i := range hits {
+		hits[i].Snippet = snippetFor(root, hits[i].Path, hits[i].Line)
 
-				is = true
-			}
+		encoded, err := json.Marshal(hits[i])
+		if err != nil {
+			return http.StatusInternalServerError, err
 		}
 
-		if !is {
-			return nil
+		if err := conn.WriteMessage(websocket.TextMessage, encoded); err != nil {
+			return http.StatusInternalServerError, err
 		}
-
-		return conn.WriteMessage(websocket.TextMessage, []byte(path))
-	})
-
-	if err != nil {
-		return http.StatusInternalServerError, err
 	}
 
 	return 0, nil